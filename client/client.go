@@ -0,0 +1,400 @@
+// Package client implements a small HTTP download client purpose-built for
+// fetching Frigate clip URLs: it resumes partial downloads with Range
+// requests, verifies the result against a caller-supplied checksum, and
+// reports progress while the transfer is in flight.
+package client
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Client downloads Requests over HTTP.
+type Client struct {
+	// HTTPClient is used for every request. Defaults to http.DefaultClient
+	// when constructed via NewClient.
+	HTTPClient *http.Client
+
+	// RequestLimiter, if set, caps the global rate of HTTP requests this
+	// Client issues across all concurrent downloads.
+	RequestLimiter *rate.Limiter
+
+	// ByteLimiter, if set, caps the global rate of bytes written to disk
+	// across all concurrent downloads.
+	ByteLimiter *rate.Limiter
+
+	// PerHostLimiter, if set, is consulted for every request to obtain a
+	// *rate.Limiter scoped to that request's host, so a worker pool can't
+	// hammer a single Frigate instance. It must return the same limiter
+	// for a given host on every call.
+	PerHostLimiter func(host string) *rate.Limiter
+
+	// MaxRetries is how many additional attempts Do makes after a
+	// transient failure (network errors, 5xx, or 429) before giving up.
+	// 0 disables retries.
+	MaxRetries int
+
+	// RetryBaseDelay is the starting delay for the exponential backoff
+	// between retries. It defaults to 500ms if unset. A 429 response's
+	// Retry-After header, when present, takes precedence over the
+	// computed backoff.
+	RetryBaseDelay time.Duration
+}
+
+// defaultRetryBaseDelay is used when a Client has retries enabled but
+// leaves RetryBaseDelay at its zero value.
+const defaultRetryBaseDelay = 500 * time.Millisecond
+
+// NewClient returns a Client backed by http.DefaultClient.
+func NewClient() *Client {
+	return &Client{HTTPClient: http.DefaultClient}
+}
+
+// Request describes a single clip to fetch.
+type Request struct {
+	// Ctx governs cancellation and timeouts for the request. Do honors it
+	// for both the HEAD probe and the GET itself.
+	Ctx context.Context
+
+	// URL is the clip location to fetch.
+	URL string
+
+	// Filename is the destination path on disk. If a file already exists
+	// there, Do treats it as a partial download and attempts to resume it
+	// with a Range request.
+	Filename string
+
+	// Hash and Checksum are optional. When both are set, Do hashes the
+	// completed file and compares it against Checksum (hex-encoded),
+	// deleting the file and returning an error on mismatch.
+	Hash     hash.Hash
+	Checksum string
+}
+
+// Response tracks the progress of a Request handed to Client.Do. It is
+// returned immediately; callers poll BytesComplete, Size, and Progress
+// while the transfer runs in the background, or call Err to block until
+// it finishes.
+type Response struct {
+	Request  *Request
+	Filename string
+
+	size    int64
+	written int64 // atomic
+
+	// status and retries are only written by Client.do, and only read
+	// after done is closed, so they need no synchronization of their own.
+	status  int
+	retries int
+
+	done chan struct{}
+	err  error
+}
+
+// BytesComplete returns the number of bytes written to disk so far,
+// including any bytes already present from a resumed partial download.
+func (resp *Response) BytesComplete() int64 {
+	return atomic.LoadInt64(&resp.written)
+}
+
+// Size returns the total size of the clip as reported by the server, or 0
+// if it is not yet known.
+func (resp *Response) Size() int64 {
+	return atomic.LoadInt64(&resp.size)
+}
+
+// Progress returns the fraction of the download complete, in [0, 1]. It
+// returns 0 until Size is known.
+func (resp *Response) Progress() float64 {
+	size := resp.Size()
+	if size <= 0 {
+		return 0
+	}
+	return float64(resp.BytesComplete()) / float64(size)
+}
+
+// Status returns the last HTTP status code seen for this download, or 0
+// if none was ever received (e.g. the request never reached the server).
+func (resp *Response) Status() int {
+	return resp.status
+}
+
+// Retries returns how many retry attempts were made after the initial
+// try.
+func (resp *Response) Retries() int {
+	return resp.retries
+}
+
+// Done returns a channel that is closed when the download finishes,
+// suitable for use in a select alongside a progress ticker.
+func (resp *Response) Done() <-chan struct{} {
+	return resp.done
+}
+
+// Err blocks until the download finishes and returns its error, if any.
+func (resp *Response) Err() error {
+	<-resp.done
+	return resp.err
+}
+
+// Do starts fetching req in the background and returns immediately with a
+// Response that can be polled for progress. Call Response.Err to wait for
+// completion and observe the result.
+func (c *Client) Do(req *Request) *Response {
+	resp := &Response{
+		Request:  req,
+		Filename: req.Filename,
+		done:     make(chan struct{}),
+	}
+	go func() {
+		resp.err = c.do(req, resp)
+		close(resp.done)
+	}()
+	return resp
+}
+
+// do fetches req, retrying transient failures with exponential backoff up
+// to c.MaxRetries times.
+func (c *Client) do(req *Request, resp *Response) error {
+	baseDelay := c.RetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	ctx := req.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var retryErr *RetryableError
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			delay := backoff(baseDelay, attempt, retryErr.RetryAfter)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			resp.retries = attempt
+		}
+
+		err := c.fetchOnce(ctx, req, resp)
+		if err == nil {
+			return nil
+		}
+		if !errors.As(err, &retryErr) || attempt >= c.MaxRetries {
+			return err
+		}
+	}
+}
+
+func (c *Client) fetchOnce(ctx context.Context, req *Request, resp *Response) error {
+	var existing int64
+	if fi, err := os.Stat(req.Filename); err == nil {
+		existing = fi.Size()
+	}
+
+	size, acceptRanges, err := c.probe(ctx, req.URL)
+	if err != nil {
+		return fmt.Errorf("probing %s: %w", req.URL, err)
+	}
+	atomic.StoreInt64(&resp.size, size)
+
+	if existing > 0 && size > 0 && existing == size {
+		// Already fully downloaded, e.g. a previous run died after the
+		// GET finished but before ffmpeg ingested the file. Skip the
+		// redundant download and just (re)verify the checksum.
+		atomic.StoreInt64(&resp.written, existing)
+		if req.Hash != nil && req.Checksum != "" {
+			if err := verifyChecksum(req.Filename, req.Hash, req.Checksum); err != nil {
+				os.Remove(req.Filename)
+				return err
+			}
+		}
+		return nil
+	}
+
+	resume := existing > 0 && acceptRanges && (size <= 0 || existing < size)
+	if existing > 0 && !resume {
+		// Can't or don't need to resume: start over.
+		existing = 0
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return fmt.Errorf("building request for %s: %w", req.URL, err)
+	}
+	flags := os.O_CREATE | os.O_WRONLY
+	if resume {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", existing))
+		flags |= os.O_APPEND
+		atomic.StoreInt64(&resp.written, existing)
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	if err := c.waitRequest(ctx, req.URL); err != nil {
+		return fmt.Errorf("rate limiting %s: %w", req.URL, err)
+	}
+	httpResp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return &RetryableError{Err: fmt.Errorf("fetching %s: %w", req.URL, err)}
+	}
+	defer httpResp.Body.Close()
+	resp.status = httpResp.StatusCode
+
+	if resume && httpResp.StatusCode == http.StatusOK {
+		// Server ignored our Range request; fall back to a full download.
+		existing = 0
+		atomic.StoreInt64(&resp.written, 0)
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	} else if httpResp.StatusCode != http.StatusOK && httpResp.StatusCode != http.StatusPartialContent {
+		statusErr := fmt.Errorf("fetching %s: unexpected status %s", req.URL, httpResp.Status)
+		if httpResp.StatusCode == http.StatusTooManyRequests {
+			return &RetryableError{Err: statusErr, RetryAfter: parseRetryAfter(httpResp.Header.Get("Retry-After"))}
+		}
+		if httpResp.StatusCode >= 500 {
+			return &RetryableError{Err: statusErr}
+		}
+		return statusErr
+	}
+
+	f, err := os.OpenFile(req.Filename, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", req.Filename, err)
+	}
+	defer f.Close()
+
+	var w io.Writer = &countingWriter{w: f, n: &resp.written}
+	if c.ByteLimiter != nil {
+		w = &rateLimitedWriter{ctx: ctx, w: w, limiter: c.ByteLimiter}
+	}
+	if _, err := io.Copy(w, httpResp.Body); err != nil {
+		return &RetryableError{Err: fmt.Errorf("downloading %s: %w", req.URL, err)}
+	}
+
+	if req.Hash != nil && req.Checksum != "" {
+		if err := verifyChecksum(req.Filename, req.Hash, req.Checksum); err != nil {
+			os.Remove(req.Filename)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// probe issues a HEAD request to learn the clip's size and whether the
+// server supports resuming via Range requests.
+func (c *Client) probe(ctx context.Context, url string) (size int64, acceptRanges bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := c.waitRequest(ctx, url); err != nil {
+		return 0, false, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, false, &RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Some servers don't support HEAD; treat size as unknown rather
+		// than failing the whole download.
+		return 0, false, nil
+	}
+
+	if cl := resp.Header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			size = n
+		}
+	}
+	acceptRanges = resp.Header.Get("Accept-Ranges") == "bytes"
+	return size, acceptRanges, nil
+}
+
+func verifyChecksum(filename string, h hash.Hash, want string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("verifying checksum of %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	h.Reset()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("verifying checksum of %s: %w", filename, err)
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	wantBytes, err := hex.DecodeString(want)
+	if err != nil {
+		return fmt.Errorf("invalid expected checksum %q: %w", want, err)
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), wantBytes) != 1 {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", filename, got, want)
+	}
+	return nil
+}
+
+// RetryableError wraps a failure encountered while fetching a clip that is
+// safe to retry: a network error, a 5xx response, or a 429 response
+// (optionally carrying the server's requested Retry-After delay).
+type RetryableError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// backoff computes the delay before the given retry attempt (1-indexed).
+// It honors retryAfter verbatim when the server supplied one; otherwise it
+// doubles base per attempt and adds up to 50% jitter.
+func backoff(base time.Duration, attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := base << uint(attempt-1)
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// parseRetryAfter parses a Retry-After header's delta-seconds form. It
+// returns 0 if the header is absent or not a plain integer (e.g. an
+// HTTP-date, which callers fall back to computed backoff for).
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	secs, err := strconv.Atoi(header)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// countingWriter wraps an io.Writer and atomically tracks total bytes
+// written so a Response can report progress while Do runs in the
+// background.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	atomic.AddInt64(cw.n, int64(n))
+	return n, err
+}
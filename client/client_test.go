@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestResumesPartialDownload(t *testing.T) {
+	full := []byte("the quick brown fox jumps over the lazy dog")
+	partial := full[:10]
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "43")
+			w.Header().Set("Accept-Ranges", "bytes")
+			return
+		}
+		rng := r.Header.Get("Range")
+		if rng != "bytes=10-" {
+			t.Errorf("expected Range bytes=10-, got %q", rng)
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(full[10:])
+	}))
+	defer srv.Close()
+
+	path := t.TempDir() + "/clip.mp4"
+	if err := os.WriteFile(path, partial, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient()
+	resp := c.Do(&Request{Ctx: context.Background(), URL: srv.URL, Filename: path})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(full) {
+		t.Fatalf("resumed file = %q, want %q", got, full)
+	}
+	if resp.Status() != http.StatusPartialContent {
+		t.Errorf("Status() = %d, want %d", resp.Status(), http.StatusPartialContent)
+	}
+}
+
+func TestSkipsRedownloadWhenAlreadyComplete(t *testing.T) {
+	body := []byte("hello world")
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "11")
+			w.Header().Set("Accept-Ranges", "bytes")
+			return
+		}
+		gets++
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	path := t.TempDir() + "/clip.mp4"
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient()
+	resp := c.Do(&Request{Ctx: context.Background(), URL: srv.URL, Filename: path})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gets != 0 {
+		t.Fatalf("expected no GET requests for an already-complete file, got %d", gets)
+	}
+	if resp.BytesComplete() != int64(len(body)) {
+		t.Fatalf("BytesComplete() = %d, want %d", resp.BytesComplete(), len(body))
+	}
+}
+
+func TestChecksumMismatchDeletesFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte("not what you expected"))
+	}))
+	defer srv.Close()
+
+	path := t.TempDir() + "/clip.mp4"
+	c := NewClient()
+	resp := c.Do(&Request{
+		Ctx:      context.Background(),
+		URL:      srv.URL,
+		Filename: path,
+		Hash:     sha256.New(),
+		Checksum: "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+
+	if err := resp.Err(); err == nil {
+		t.Fatal("expected a checksum mismatch error, got nil")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected file to be removed after checksum mismatch, stat err = %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+
+	"golang.org/x/time/rate"
+)
+
+// waitRequest blocks until both the global RequestLimiter and any
+// PerHostLimiter for rawURL's host admit another request. Either limiter
+// may be nil, in which case it imposes no limit.
+func (c *Client) waitRequest(ctx context.Context, rawURL string) error {
+	if c.RequestLimiter != nil {
+		if err := c.RequestLimiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if c.PerHostLimiter != nil {
+		host := rawURL
+		if u, err := url.Parse(rawURL); err == nil {
+			host = u.Host
+		}
+		if err := c.PerHostLimiter(host).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rateLimitedWriter wraps an io.Writer so that every Write first consumes
+// len(p) tokens from limiter, capping the rate of bytes flowing through
+// to w.
+type rateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (rw *rateLimitedWriter) Write(p []byte) (int, error) {
+	if err := rw.limiter.WaitN(rw.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return rw.w.Write(p)
+}
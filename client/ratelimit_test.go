@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestWaitRequestThrottlesOnRequestLimiter(t *testing.T) {
+	c := NewClient()
+	c.RequestLimiter = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+
+	ctx := context.Background()
+	if err := c.waitRequest(ctx, "http://example.com/a"); err != nil {
+		t.Fatalf("first waitRequest: %v", err)
+	}
+
+	start := time.Now()
+	if err := c.waitRequest(ctx, "http://example.com/b"); err != nil {
+		t.Fatalf("second waitRequest: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("second waitRequest returned after %v, want it delayed by the request limiter", elapsed)
+	}
+}
+
+func TestWaitRequestPerHostLimitersAreIndependent(t *testing.T) {
+	c := NewClient()
+	limiters := map[string]*rate.Limiter{}
+	c.PerHostLimiter = func(host string) *rate.Limiter {
+		l, ok := limiters[host]
+		if !ok {
+			l = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+			limiters[host] = l
+		}
+		return l
+	}
+
+	ctx := context.Background()
+	if err := c.waitRequest(ctx, "http://host-a.example/clip.mp4"); err != nil {
+		t.Fatalf("exhausting host-a's burst: %v", err)
+	}
+
+	start := time.Now()
+	if err := c.waitRequest(ctx, "http://host-b.example/clip.mp4"); err != nil {
+		t.Fatalf("waitRequest for host-b: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("host-b request waited %v, want it unaffected by host-a's exhausted bucket", elapsed)
+	}
+
+	start = time.Now()
+	if err := c.waitRequest(ctx, "http://host-a.example/clip.mp4"); err != nil {
+		t.Fatalf("second waitRequest for host-a: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("second host-a request returned after %v, want it delayed by host-a's own limiter", elapsed)
+	}
+}
+
+func TestRateLimitedWriterThrottlesByBytes(t *testing.T) {
+	var buf bytes.Buffer
+	rw := &rateLimitedWriter{
+		ctx:     context.Background(),
+		w:       &buf,
+		limiter: rate.NewLimiter(rate.Limit(10), 10),
+	}
+
+	if _, err := rw.Write([]byte("0123456789")); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := rw.Write([]byte("x")); err != nil {
+		t.Fatalf("second write: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("second write returned after %v, want it delayed until a token refilled", elapsed)
+	}
+
+	if buf.String() != "0123456789x" {
+		t.Errorf("buf = %q, want %q", buf.String(), "0123456789x")
+	}
+}
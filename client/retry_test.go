@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffHonorsRetryAfter(t *testing.T) {
+	got := backoff(100*time.Millisecond, 3, 5*time.Second)
+	if got != 5*time.Second {
+		t.Errorf("backoff() = %v, want the server-supplied Retry-After of 5s", got)
+	}
+}
+
+func TestBackoffDoublesWithJitterBounds(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 1; attempt <= 5; attempt++ {
+		want := base << uint(attempt-1)
+		for i := 0; i < 20; i++ {
+			got := backoff(base, attempt, 0)
+			if got < want || got > want+want/2 {
+				t.Fatalf("attempt %d: backoff() = %v, want in [%v, %v]", attempt, got, want, want+want/2)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 120 * time.Second},
+		{"0", 0},
+		{"-5", 0},
+		{"not-a-number", 0},
+	}
+	for _, tc := range cases {
+		if got := parseRetryAfter(tc.header); got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestDoRetriesTransientFailures(t *testing.T) {
+	var gets int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		if atomic.AddInt32(&gets, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.MaxRetries = 3
+	c.RetryBaseDelay = time.Millisecond
+
+	path := t.TempDir() + "/clip.mp4"
+	resp := c.Do(&Request{Ctx: context.Background(), URL: srv.URL, Filename: path})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("expected eventual success after retries, got: %v", err)
+	}
+	if resp.Retries() != 2 {
+		t.Errorf("Retries() = %d, want 2", resp.Retries())
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ok" {
+		t.Errorf("downloaded content = %q, want %q", got, "ok")
+	}
+}
+
+func TestDoGivesUpAfterMaxRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.MaxRetries = 2
+	c.RetryBaseDelay = time.Millisecond
+
+	resp := c.Do(&Request{Ctx: context.Background(), URL: srv.URL, Filename: t.TempDir() + "/clip.mp4"})
+	if err := resp.Err(); err == nil {
+		t.Fatal("expected an error after exhausting retries, got nil")
+	}
+	if resp.Retries() != 2 {
+		t.Errorf("Retries() = %d, want 2", resp.Retries())
+	}
+}
@@ -1,54 +1,445 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/kamenmackay/dailyfrigate/client"
+	"github.com/kamenmackay/dailyfrigate/frigate"
+	"github.com/kamenmackay/dailyfrigate/outputname"
 )
 
-func downloadClip(url string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// byteLimiterBurst is the token bucket size used for the global byte-rate
+// limiter. It matches io.Copy's default internal buffer size so a single
+// Write never asks for more tokens than the bucket can hold.
+const byteLimiterBurst = 32 * 1024
+
+// job is a single clip to download. Camera and EventID are populated by
+// `sync` mode and left blank when a clip comes from a bare URL on the
+// command line.
+type job struct {
+	index   int
+	url     string
+	camera  string
+	eventID string
+}
+
+// result is what became of a job, collected so main can print a summary
+// table and decide the process's exit code.
+type result struct {
+	job        job
+	httpStatus int
+	retries    int
+	ffmpegExit int // -1 if ffmpeg never ran
+	err        error
+}
 
-	// Send HTTP request
-	resp, err := http.Get(url)
+func downloadClip(ctx context.Context, c *client.Client, outputTemplate string, j job) result {
+	outFile := outputname.Render(outputTemplate, outputname.Fields{
+		Index:   j.index,
+		URL:     j.url,
+		Camera:  j.camera,
+		EventID: j.eventID,
+	})
+	rawFile := outFile + ".download"
+
+	req := &client.Request{
+		Ctx:      ctx,
+		URL:      j.url,
+		Filename: rawFile,
+	}
+	resp := c.Do(req)
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-resp.Done():
+			break loop
+		case <-ticker.C:
+			fmt.Printf("%s: %d/%d bytes (%.0f%%)\n", j.url, resp.BytesComplete(), resp.Size(), resp.Progress()*100)
+		}
+	}
+
+	r := result{job: j, ffmpegExit: -1}
+	if err := resp.Err(); err != nil {
+		r.err = fmt.Errorf("fetching URL %s: %w", j.url, err)
+		r.httpStatus = resp.Status()
+		r.retries = resp.Retries()
+		return r
+	}
+	r.httpStatus = resp.Status()
+	r.retries = resp.Retries()
+
+	ingested, exitCode, err := runFfmpeg(rawFile, outFile)
+	r.ffmpegExit = exitCode
 	if err != nil {
-		fmt.Printf("Error fetching URL %s: %v\n", url, err)
-		return
+		// Leave rawFile in place: it's a complete download, and the
+		// client's resume/skip-redownload logic will
+		// reuse it on the next attempt instead of re-fetching over HTTP.
+		r.err = fmt.Errorf("running ffmpeg for URL %s: %w", j.url, err)
+		return r
 	}
-	defer resp.Body.Close()
+	os.Remove(rawFile)
+
+	fmt.Printf("Clip downloaded from URL %s -> %s (%d bytes ingested by ffmpeg)\n", j.url, outFile, ingested)
+	return r
+}
 
-	// Read response body
-	body, err := ioutil.ReadAll(resp.Body)
+// runFfmpeg remuxes the downloaded clip at rawFile into outFile, streaming
+// it through ffmpeg's stdin rather than reading it into memory first.
+// ffmpeg's stderr is captured to outFile+".log" so failures are
+// diagnosable. It returns the number of bytes fed to ffmpeg and ffmpeg's
+// exit code (-1 if the process never started).
+func runFfmpeg(rawFile, outFile string) (int64, int, error) {
+	in, err := os.Open(rawFile)
 	if err != nil {
-		fmt.Printf("Error reading response body for URL %s: %v\n", url, err)
-		return
+		return 0, -1, fmt.Errorf("opening downloaded clip: %w", err)
 	}
+	defer in.Close()
 
-	// Run ffmpeg command to save MP4 clip to file
-	cmd := exec.Command("ffmpeg", "-movflags", "frag_keyframe+empty_moov", "-i", "pipe:0", "-c", "copy", "-y", "clip.mp4")
-	cmd.Stdin = strings.NewReader(string(body))
-	err = cmd.Run()
+	logFile, err := os.Create(outFile + ".log")
 	if err != nil {
-		fmt.Printf("Error running ffmpeg command for URL %s: %v\n", url, err)
-		return
+		return 0, -1, fmt.Errorf("creating ffmpeg log: %w", err)
 	}
+	defer logFile.Close()
 
-	fmt.Printf("Clip downloaded from URL %s\n", url)
+	var ingested byteCounter
+	cmd := exec.Command("ffmpeg", "-movflags", "frag_keyframe+empty_moov", "-i", "pipe:0", "-c", "copy", "-y", outFile)
+	cmd.Stdin = io.TeeReader(in, &ingested)
+	cmd.Stderr = logFile
+
+	runErr := cmd.Run()
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	if runErr != nil {
+		return ingested.n, exitCode, fmt.Errorf("ffmpeg failed, see %s: %w", outFile+".log", runErr)
+	}
+	return ingested.n, exitCode, nil
 }
 
-func main() {
-	// Get URLs from command-line arguments
-	urls := os.Args[1:]
+// byteCounter is an io.Writer that only counts the bytes written to it,
+// used as the tee destination when streaming a clip into ffmpeg.
+type byteCounter struct{ n int64 }
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// worker pulls jobs until it's closed, downloading each in turn and
+// reporting its outcome on results.
+func worker(ctx context.Context, c *client.Client, outputTemplate string, jobs <-chan job, results chan<- result, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		results <- downloadClip(ctx, c, outputTemplate, j)
+	}
+}
+
+// runJobs downloads jobs across a pool of concurrency workers and returns
+// once every job has finished, in no particular order. A non-positive
+// concurrency is treated as 1, since 0 workers would never drain jobs.
+func runJobs(ctx context.Context, c *client.Client, outputTemplate string, concurrency int, jobs []job) []result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ch := make(chan job)
+	results := make(chan result, len(jobs))
 
-	// Download each clip concurrently
 	var wg sync.WaitGroup
-	for _, url := range urls {
+	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
-		go downloadClip(url, &wg)
+		go worker(ctx, c, outputTemplate, ch, results, &wg)
 	}
+	for _, j := range jobs {
+		ch <- j
+	}
+	close(ch)
 	wg.Wait()
+	close(results)
+
+	all := make([]result, 0, len(jobs))
+	for r := range results {
+		all = append(all, r)
+	}
+	return all
+}
+
+// printFailures writes a summary table of any failed results to stderr and
+// reports whether there were any.
+func printFailures(results []result) bool {
+	var failed []result
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d of %d clip(s) failed:\n", len(failed), len(results))
+	w := tabwriter.NewWriter(os.Stderr, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tHTTP STATUS\tFFMPEG EXIT\tRETRIES\tERROR")
+	for _, r := range failed {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%v\n", r.job.url, r.httpStatus, r.ffmpegExit, r.retries, r.err)
+	}
+	w.Flush()
+	return true
+}
+
+// newHostLimiter returns a PerHostLimiter func that lazily creates one
+// rate.Limiter per host, each allowing rps requests/sec.
+func newHostLimiter(rps float64) func(host string) *rate.Limiter {
+	var mu sync.Mutex
+	limiters := make(map[string]*rate.Limiter)
+	return func(host string) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+		l, ok := limiters[host]
+		if !ok {
+			l = rate.NewLimiter(rate.Limit(rps), 1)
+			limiters[host] = l
+		}
+		return l
+	}
+}
+
+// clientConfig bundles the flags shared by runDownload and runSync for
+// building a client.Client.
+type clientConfig struct {
+	globalRate     float64
+	globalRPS      float64
+	hostRPS        float64
+	maxRetries     int
+	retryBaseDelay time.Duration
+}
+
+func (cfg clientConfig) newClient() *client.Client {
+	c := client.NewClient()
+	if cfg.globalRate > 0 {
+		c.ByteLimiter = rate.NewLimiter(rate.Limit(cfg.globalRate), byteLimiterBurst)
+	}
+	if cfg.globalRPS > 0 {
+		c.RequestLimiter = rate.NewLimiter(rate.Limit(cfg.globalRPS), 1)
+	}
+	if cfg.hostRPS > 0 {
+		c.PerHostLimiter = newHostLimiter(cfg.hostRPS)
+	}
+	c.MaxRetries = cfg.maxRetries
+	c.RetryBaseDelay = cfg.retryBaseDelay
+	return c
+}
+
+func addClientFlags(fs *flag.FlagSet) *clientConfig {
+	cfg := &clientConfig{}
+	fs.Float64Var(&cfg.globalRate, "rate", 0, "global download rate limit in bytes/sec across all workers (0 = unlimited)")
+	fs.Float64Var(&cfg.globalRPS, "rps", 0, "global HTTP requests/sec limit across all workers (0 = unlimited)")
+	fs.Float64Var(&cfg.hostRPS, "host-rps", 0, "HTTP requests/sec limit per Frigate host (0 = unlimited)")
+	fs.IntVar(&cfg.maxRetries, "max-retries", 3, "maximum retry attempts for a transient fetch failure")
+	fs.DurationVar(&cfg.retryBaseDelay, "retry-base-delay", 500*time.Millisecond, "base delay for retry backoff (doubles each attempt, subject to jitter)")
+	return cfg
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sync" {
+		runSync(os.Args[2:])
+		return
+	}
+	runDownload(os.Args[1:])
+}
+
+// runDownload is the original mode: fetch clips from URLs passed as
+// command-line arguments.
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("dailyfrigate", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of clips to download concurrently")
+	outputTemplate := fs.String("output-template", "", "filename template for downloaded clips, e.g. {camera}-{event_id}.mp4 (defaults to a name derived from each URL)")
+	cfg := addClientFlags(fs)
+	fs.Parse(args)
+
+	c := cfg.newClient()
+
+	jobs := make([]job, len(fs.Args()))
+	for i, url := range fs.Args() {
+		jobs[i] = job{index: i, url: url}
+	}
+	results := runJobs(context.Background(), c, *outputTemplate, *concurrency, jobs)
+	if printFailures(results) {
+		os.Exit(1)
+	}
+}
+
+// runSync is the `sync` subcommand: poll a Frigate instance's events API
+// for new clips and download any that haven't been seen before, optionally
+// repeating on an interval so it can run as a long-lived daemon.
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	frigateURL := fs.String("frigate-url", "", "base URL of the Frigate instance, e.g. http://frigate.local:5000 (required)")
+	since := fs.Duration("since", 24*time.Hour, "sync events that started within this long ago")
+	cameras := fs.String("cameras", "", "comma-separated camera names to sync (default: all)")
+	labels := fs.String("labels", "", "comma-separated labels to sync (default: all)")
+	interval := fs.Duration("interval", 0, "if set, re-sync on this interval instead of running once")
+	stateFile := fs.String("state-file", "dailyfrigate-state.json", "path to the dedup state file")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of clips to download concurrently")
+	outputTemplate := fs.String("output-template", "{camera}-{event_id}.mp4", "filename template for downloaded clips")
+	cfg := addClientFlags(fs)
+	fs.Parse(args)
+
+	if *frigateURL == "" {
+		fmt.Fprintln(os.Stderr, "sync: --frigate-url is required")
+		os.Exit(1)
+	}
+
+	fc := frigate.NewClient(*frigateURL)
+	store, err := frigate.OpenStore(*stateFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sync:", err)
+		os.Exit(1)
+	}
+
+	c := cfg.newClient()
+
+	for {
+		failed, err := syncOnce(context.Background(), fc, store, c, syncOptions{
+			frigateURL:     *frigateURL,
+			since:          *since,
+			cameras:        splitNonEmpty(*cameras),
+			labels:         splitNonEmpty(*labels),
+			concurrency:    *concurrency,
+			outputTemplate: *outputTemplate,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "sync:", err)
+		}
+
+		if *interval <= 0 {
+			if err != nil || failed {
+				os.Exit(1)
+			}
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+type syncOptions struct {
+	frigateURL     string
+	since          time.Duration
+	cameras        []string
+	labels         []string
+	concurrency    int
+	outputTemplate string
+}
+
+// syncOnce discovers new events since opts.since, downloads their clips,
+// and records the successful ones in store so they're skipped on the next
+// sync. It reports whether any clip failed to download.
+func syncOnce(ctx context.Context, fc *frigate.Client, store *frigate.Store, c *client.Client, opts syncOptions) (bool, error) {
+	events, err := discoverEvents(ctx, fc, opts)
+	if err != nil {
+		return false, fmt.Errorf("listing events: %w", err)
+	}
+
+	var jobs []job
+	for _, e := range events {
+		if store.Seen(e.ID) {
+			continue
+		}
+		jobs = append(jobs, job{
+			index:   len(jobs),
+			url:     e.ClipURL(opts.frigateURL),
+			camera:  e.Camera,
+			eventID: e.ID,
+		})
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("sync: no new events")
+		return false, nil
+	}
+
+	fmt.Printf("sync: downloading %d new clip(s)\n", len(jobs))
+	results := runJobs(ctx, c, opts.outputTemplate, opts.concurrency, jobs)
+	failed := printFailures(results)
+
+	markSeenOnSuccess(store, results)
+	if err := store.Save(); err != nil {
+		return failed, fmt.Errorf("saving state: %w", err)
+	}
+	return failed, nil
+}
+
+// markSeenOnSuccess records each result's event ID in store, but only for
+// jobs that downloaded without error. A job that failed (transient
+// network error, ffmpeg crash, etc.) must stay unseen so the next sync
+// retries it instead of dropping it permanently.
+func markSeenOnSuccess(store *frigate.Store, results []result) {
+	for _, r := range results {
+		if r.err == nil {
+			store.MarkSeen(r.job.eventID)
+		}
+	}
+}
+
+// discoverEvents queries Frigate for events matching opts, issuing one
+// query per camera and label pair since the events API filters on a
+// single camera and label at a time, and deduplicating by event ID across
+// queries.
+func discoverEvents(ctx context.Context, fc *frigate.Client, opts syncOptions) ([]frigate.Event, error) {
+	cameras := opts.cameras
+	if len(cameras) == 0 {
+		cameras = []string{""}
+	}
+	labels := opts.labels
+	if len(labels) == 0 {
+		labels = []string{""}
+	}
+
+	after := time.Now().Add(-opts.since)
+	seen := make(map[string]bool)
+	var all []frigate.Event
+	for _, camera := range cameras {
+		for _, label := range labels {
+			events, err := fc.Events(ctx, frigate.Filter{
+				Camera:  camera,
+				Label:   label,
+				After:   after,
+				HasClip: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range events {
+				if seen[e.ID] {
+					continue
+				}
+				seen[e.ID] = true
+				all = append(all, e)
+			}
+		}
+	}
+	return all, nil
+}
+
+func splitNonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kamenmackay/dailyfrigate/client"
+	"github.com/kamenmackay/dailyfrigate/frigate"
+)
+
+func TestMarkSeenOnSuccessSkipsFailedJobs(t *testing.T) {
+	store, err := frigate.OpenStore(t.TempDir() + "/state.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []result{
+		{job: job{eventID: "ok"}, err: nil},
+		{job: job{eventID: "bad"}, err: errors.New("ffmpeg failed")},
+	}
+	markSeenOnSuccess(store, results)
+
+	if !store.Seen("ok") {
+		t.Error("expected successfully downloaded event to be marked seen")
+	}
+	if store.Seen("bad") {
+		t.Error("expected failed event to stay unseen so it's retried next sync")
+	}
+}
+
+func TestRunJobsNonPositiveConcurrencyDoesNotDeadlock(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "2")
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	jobs := []job{{index: 0, url: srv.URL}}
+	outputTemplate := t.TempDir() + "/{index}.mp4"
+
+	done := make(chan []result, 1)
+	go func() {
+		done <- runJobs(context.Background(), client.NewClient(), outputTemplate, 0, jobs)
+	}()
+
+	select {
+	case results := <-done:
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runJobs with concurrency <= 0 deadlocked instead of running with at least 1 worker")
+	}
+}
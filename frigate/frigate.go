@@ -0,0 +1,124 @@
+// Package frigate talks to a Frigate NVR's HTTP API to discover clip
+// events available for download.
+package frigate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventsPageSize is how many events are requested per page when Events
+// pages through Frigate's /api/events endpoint.
+const eventsPageSize = 100
+
+// Client queries a single Frigate instance's events API.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for the Frigate instance at baseURL, e.g.
+// "http://frigate.local:5000".
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Filter narrows which events Events returns. Zero values mean "no
+// filter" for that field.
+type Filter struct {
+	Camera  string
+	Label   string
+	After   time.Time
+	HasClip bool
+}
+
+// Event is a single detection recorded by Frigate.
+type Event struct {
+	ID        string  `json:"id"`
+	Camera    string  `json:"camera"`
+	Label     string  `json:"label"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	HasClip   bool    `json:"has_clip"`
+}
+
+// ClipURL returns the URL of e's clip on the Frigate instance at baseURL.
+func (e Event) ClipURL(baseURL string) string {
+	return strings.TrimRight(baseURL, "/") + "/api/events/" + e.ID + "/clip.mp4"
+}
+
+// Events returns every event matching filter, paging through Frigate's
+// /api/events endpoint until it finds one that started before
+// filter.After or a page comes back with fewer than eventsPageSize
+// events.
+func (c *Client) Events(ctx context.Context, filter Filter) ([]Event, error) {
+	var all []Event
+	before := time.Time{}
+	for {
+		page, err := c.eventsPage(ctx, filter, before, eventsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range page {
+			if !filter.After.IsZero() && e.StartTime < float64(filter.After.Unix()) {
+				return all, nil
+			}
+			all = append(all, e)
+		}
+		if len(page) < eventsPageSize {
+			return all, nil
+		}
+		before = time.Unix(int64(page[len(page)-1].StartTime), 0)
+	}
+}
+
+// eventsPage fetches a single page of events, the most recent one
+// starting strictly before the given time (or the most recent events at
+// all, if before is zero).
+func (c *Client) eventsPage(ctx context.Context, filter Filter, before time.Time, limit int) ([]Event, error) {
+	q := url.Values{}
+	if filter.Camera != "" {
+		q.Set("camera", filter.Camera)
+	}
+	if filter.Label != "" {
+		q.Set("label", filter.Label)
+	}
+	if filter.HasClip {
+		q.Set("has_clip", "1")
+	}
+	if !before.IsZero() {
+		q.Set("before", strconv.FormatInt(before.Unix(), 10))
+	}
+	q.Set("limit", strconv.Itoa(limit))
+
+	reqURL := c.BaseURL + "/api/events?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building events request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching events: unexpected status %s", resp.Status)
+	}
+
+	var events []Event
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, fmt.Errorf("decoding events: %w", err)
+	}
+	return events, nil
+}
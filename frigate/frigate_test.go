@@ -0,0 +1,103 @@
+package frigate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventsPagesUntilShortPage(t *testing.T) {
+	page1 := make([]Event, eventsPageSize)
+	for i := range page1 {
+		page1[i] = Event{ID: "p1-" + strconv.Itoa(i), StartTime: float64(1000 - i)}
+	}
+	page2 := []Event{
+		{ID: "p2-0", StartTime: 900},
+		{ID: "p2-1", StartTime: 899},
+	}
+
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		var page []Event
+		if r.URL.Query().Get("before") == "" {
+			page = page1
+		} else {
+			page = page2
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	events, err := c.Events(context.Background(), Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gets != 2 {
+		t.Fatalf("expected 2 page requests, got %d", gets)
+	}
+	if len(events) != len(page1)+len(page2) {
+		t.Fatalf("got %d events, want %d", len(events), len(page1)+len(page2))
+	}
+}
+
+func TestEventsStopsAtAfterCutoffMidPage(t *testing.T) {
+	page := []Event{
+		{ID: "e0", StartTime: 1000},
+		{ID: "e1", StartTime: 999},
+		{ID: "e2", StartTime: 500},
+		{ID: "e3", StartTime: 499},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	events, err := c.Events(context.Background(), Filter{After: time.Unix(999, 0)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (stop before the event older than After)", len(events))
+	}
+	if events[0].ID != "e0" || events[1].ID != "e1" {
+		t.Fatalf("got events %+v, want e0 and e1 only", events)
+	}
+}
+
+func TestEventsPageQueryParams(t *testing.T) {
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		json.NewEncoder(w).Encode([]Event{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	_, err := c.Events(context.Background(), Filter{
+		Camera:  "driveway",
+		Label:   "person",
+		HasClip: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := gotQuery.Get("camera"); got != "driveway" {
+		t.Errorf("camera = %q, want %q", got, "driveway")
+	}
+	if got := gotQuery.Get("label"); got != "person" {
+		t.Errorf("label = %q, want %q", got, "person")
+	}
+	if got := gotQuery.Get("has_clip"); got != "1" {
+		t.Errorf("has_clip = %q, want %q", got, "1")
+	}
+}
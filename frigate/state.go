@@ -0,0 +1,66 @@
+package frigate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Store is a small JSON-backed set of event IDs that have already been
+// downloaded, so repeated syncs can skip them.
+type Store struct {
+	path string
+	seen map[string]bool
+}
+
+// OpenStore loads the dedup state from path. A missing file is treated as
+// an empty store rather than an error.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, seen: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file %s: %w", path, err)
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("parsing state file %s: %w", path, err)
+	}
+	for _, id := range ids {
+		s.seen[id] = true
+	}
+	return s, nil
+}
+
+// Seen reports whether id has already been downloaded.
+func (s *Store) Seen(id string) bool {
+	return s.seen[id]
+}
+
+// MarkSeen records id as downloaded. Call Save to persist it.
+func (s *Store) MarkSeen(id string) {
+	s.seen[id] = true
+}
+
+// Save writes the current set of seen event IDs back to the store's file.
+func (s *Store) Save() error {
+	ids := make([]string, 0, len(s.seen))
+	for id := range s.seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state file %s: %w", s.path, err)
+	}
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("writing state file %s: %w", s.path, err)
+	}
+	return nil
+}
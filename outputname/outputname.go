@@ -0,0 +1,69 @@
+// Package outputname renders destination filenames for downloaded clips,
+// either from a user-supplied --output-template or, failing that, a name
+// derived from the clip's source URL.
+package outputname
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Fields are the placeholders available when rendering a filename.
+// Camera and EventID are populated in `sync` mode, where clips come from
+// the Frigate events API; they're left blank when a clip is downloaded
+// from a bare URL on the command line.
+type Fields struct {
+	Index   int
+	URL     string
+	Camera  string
+	EventID string
+}
+
+// Render expands template, substituting {index}, {url}, {camera}, and
+// {event_id}. If template is empty, it falls back to a name derived from
+// the URL's path, or clip-<index>.mp4 if the URL has no usable path.
+func Render(template string, f Fields) string {
+	if template == "" {
+		return defaultName(f)
+	}
+	replacer := strings.NewReplacer(
+		"{index}", strconv.Itoa(f.Index),
+		"{url}", urlName(f.URL),
+		"{camera}", f.Camera,
+		"{event_id}", f.EventID,
+	)
+	name := replacer.Replace(template)
+	if !strings.HasSuffix(name, ".mp4") {
+		name += ".mp4"
+	}
+	return name
+}
+
+func defaultName(f Fields) string {
+	if name := urlName(f.URL); name != "" {
+		return name + ".mp4"
+	}
+	return fmt.Sprintf("clip-%d.mp4", f.Index)
+}
+
+// urlName returns a filesystem-safe name derived from the URL's full
+// path, or "" if the URL doesn't parse or has no usable path component.
+// It uses the whole path rather than just the final segment because
+// Frigate clip URLs all end in the same "clip.mp4" segment
+// (/api/events/<event_id>/clip.mp4); keying off that alone would collapse
+// every event onto the same filename.
+func urlName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	trimmed := strings.Trim(u.Path, "/")
+	trimmed = strings.TrimSuffix(trimmed, path.Ext(trimmed))
+	if trimmed == "" {
+		return ""
+	}
+	return strings.ReplaceAll(trimmed, "/", "-")
+}
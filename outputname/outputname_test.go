@@ -0,0 +1,58 @@
+package outputname
+
+import "testing"
+
+func TestDefaultNameDistinguishesSameBasename(t *testing.T) {
+	a := Render("", Fields{Index: 0, URL: "http://frigate.local/api/events/aaa/clip.mp4"})
+	b := Render("", Fields{Index: 1, URL: "http://frigate.local/api/events/bbb/clip.mp4"})
+
+	if a == b {
+		t.Fatalf("two different event URLs both rendered to %q, want distinct names", a)
+	}
+	if a != "api-events-aaa-clip.mp4" {
+		t.Errorf("got %q, want %q", a, "api-events-aaa-clip.mp4")
+	}
+	if b != "api-events-bbb-clip.mp4" {
+		t.Errorf("got %q, want %q", b, "api-events-bbb-clip.mp4")
+	}
+}
+
+func TestDefaultNameFallsBackWithoutUsablePath(t *testing.T) {
+	cases := []struct {
+		name string
+		f    Fields
+		want string
+	}{
+		{"empty URL", Fields{Index: 3, URL: ""}, "clip-3.mp4"},
+		{"root path only", Fields{Index: 5, URL: "http://frigate.local/"}, "clip-5.mp4"},
+		{"unparsable URL", Fields{Index: 7, URL: "http://[::1"}, "clip-7.mp4"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Render("", tc.f); got != tc.want {
+				t.Errorf("Render(%q, %+v) = %q, want %q", "", tc.f, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderExpandsPlaceholders(t *testing.T) {
+	got := Render("{camera}-{event_id}-{index}", Fields{
+		Index:   2,
+		URL:     "http://frigate.local/api/events/ccc/clip.mp4",
+		Camera:  "driveway",
+		EventID: "ccc",
+	})
+	want := "driveway-ccc-2.mp4"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderDoesNotDoubleAppendExtension(t *testing.T) {
+	got := Render("{event_id}.mp4", Fields{EventID: "ddd"})
+	want := "ddd.mp4"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}